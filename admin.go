@@ -0,0 +1,130 @@
+package main
+
+import (
+	"crypto/subtle"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/seanogor/blogaggregator.git/internal/database"
+)
+
+const (
+	defaultPageLimit = 20
+	maxPageLimit     = 100
+)
+
+// middlewareAdmin guards the admin sub-router. Requests are let through with
+// the configured admin key in the X-Admin-Key header or, when ADMIN_USER and
+// ADMIN_PASS are set, with matching HTTP Basic credentials so the admin API can
+// be used from a browser. With neither configured the admin API is disabled.
+func middlewareAdmin(apiCfg *apiConfig, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		basicAuthEnabled := apiCfg.AdminUser != "" && apiCfg.AdminPass != ""
+		if apiCfg.AdminKey == "" && !basicAuthEnabled {
+			respondWithError(w, http.StatusForbidden, "Admin API is disabled")
+			return
+		}
+
+		// An admin key, when sent, has to be the right one
+		if key := r.Header.Get("X-Admin-Key"); key != "" && apiCfg.AdminKey != "" {
+			if !secureCompare(key, apiCfg.AdminKey) {
+				respondWithError(w, http.StatusUnauthorized, "Invalid admin key")
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if basicAuthEnabled {
+			user, pass, ok := r.BasicAuth()
+			userOK := secureCompare(user, apiCfg.AdminUser)
+			passOK := secureCompare(pass, apiCfg.AdminPass)
+			if !ok || !userOK || !passOK {
+				w.Header().Set("WWW-Authenticate", `Basic realm="blogaggregator admin", charset="UTF-8"`)
+				respondWithError(w, http.StatusUnauthorized, "Invalid admin credentials")
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		respondWithError(w, http.StatusUnauthorized, "Invalid admin key")
+	})
+}
+
+// secureCompare reports whether a and b are equal in constant time.
+func secureCompare(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
+
+// parsePagination reads the limit and offset query parameters, applying the
+// default limit and capping it at maxPageLimit. Offsets must fit the int32
+// the queries take.
+func parsePagination(r *http.Request) (limit, offset int, ok bool) {
+	limit, offset = defaultPageLimit, 0
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return 0, 0, false
+		}
+		limit = min(n, maxPageLimit)
+	}
+	if v := r.URL.Query().Get("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 || n > math.MaxInt32 {
+			return 0, 0, false
+		}
+		offset = n
+	}
+	return limit, offset, true
+}
+
+// listUsersHandler returns a page of users, selected with the limit and
+// offset query parameters, along with the total count.
+func listUsersHandler(apiCfg *apiConfig) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+			return
+		}
+
+		limit, offset, ok := parsePagination(r)
+		if !ok {
+			respondWithError(w, http.StatusBadRequest, "Invalid limit or offset")
+			return
+		}
+
+		// Load the page of users and the total count
+		ctx, span := tracer.Start(r.Context(), "db.ListUsers")
+		users, err := apiCfg.Store.ListUsers(ctx, database.ListUsersParams{
+			Limit:  int32(limit),
+			Offset: int32(offset),
+		})
+		if err != nil {
+			span.End()
+			respondWithError(w, mapErrorToStatus(err), "Failed to list users")
+			return
+		}
+		total, err := apiCfg.Store.CountUsers(ctx)
+		span.End()
+		if err != nil {
+			respondWithError(w, mapErrorToStatus(err), "Failed to count users")
+			return
+		}
+
+		// Respond with the users
+		format := apiCfg.requestTimeFormat(w, r)
+		items := make([]map[string]interface{}, 0, len(users))
+		for _, user := range users {
+			items = append(items, userResponse(user, format))
+		}
+		respondWithJSON(w, http.StatusOK, map[string]interface{}{
+			"users":  items,
+			"total":  total,
+			"limit":  limit,
+			"offset": offset,
+		})
+	}
+}
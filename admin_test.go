@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/seanogor/blogaggregator.git/internal/database"
+	"github.com/seanogor/blogaggregator.git/internal/store"
+)
+
+func TestListUsersHandler(t *testing.T) {
+	s := store.NewMemory()
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, name := range []string{"alice", "bob", "carol"} {
+		createdAt := start.Add(time.Duration(i) * time.Hour)
+		_, err := s.CreateUser(context.Background(), database.CreateUserParams{
+			ID:        uuid.New(),
+			CreatedAt: createdAt,
+			UpdatedAt: createdAt,
+			Name:      name,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	apiCfg := &apiConfig{Store: s, TimeFormat: timeFormatRFC3339}
+
+	tests := []struct {
+		name       string
+		query      string
+		wantStatus int
+		wantNames  []string
+	}{
+		{"default page", "", http.StatusOK, []string{"alice", "bob", "carol"}},
+		{"limit and offset", "?limit=1&offset=1", http.StatusOK, []string{"bob"}},
+		{"offset past end", "?offset=10", http.StatusOK, []string{}},
+		{"negative offset", "?offset=-1", http.StatusBadRequest, nil},
+		{"offset overflows int32", "?offset=2147483648", http.StatusBadRequest, nil},
+		{"zero limit", "?limit=0", http.StatusBadRequest, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/v1/admin/users"+tt.query, nil)
+			listUsersHandler(apiCfg)(w, r)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if tt.wantNames == nil {
+				return
+			}
+
+			var resp struct {
+				Users []struct {
+					Name string `json:"name"`
+				} `json:"users"`
+				Total int64 `json:"total"`
+			}
+			if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+				t.Fatal(err)
+			}
+			if resp.Total != 3 {
+				t.Errorf("total %d, want 3", resp.Total)
+			}
+			names := []string{}
+			for _, user := range resp.Users {
+				names = append(names, user.Name)
+			}
+			if !slices.Equal(names, tt.wantNames) {
+				t.Errorf("users %v, want %v", names, tt.wantNames)
+			}
+		})
+	}
+
+	for _, tt := range []struct {
+		method     string
+		wantStatus int
+	}{
+		{http.MethodHead, http.StatusOK},
+		{http.MethodPost, http.StatusMethodNotAllowed},
+		{http.MethodDelete, http.StatusMethodNotAllowed},
+	} {
+		t.Run(tt.method, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			listUsersHandler(apiCfg)(w, httptest.NewRequest(tt.method, "/v1/admin/users", nil))
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if tt.wantStatus == http.StatusMethodNotAllowed && w.Header().Get("Allow") != "GET, HEAD" {
+				t.Errorf("Allow %q, want %q", w.Header().Get("Allow"), "GET, HEAD")
+			}
+		})
+	}
+}
+
+func TestMiddlewareAdmin(t *testing.T) {
+	const (
+		key  = "admin-key"
+		user = "admin"
+		pass = "secret"
+	)
+	type credentials struct {
+		key        string
+		user, pass string
+	}
+	tests := []struct {
+		name        string
+		cfgKey      string
+		basic       bool
+		creds       credentials
+		wantStatus  int
+		wantWWWAuth bool
+	}{
+		{"disabled", "", false, credentials{key: key}, http.StatusForbidden, false},
+		{"key only, valid key", key, false, credentials{key: key}, http.StatusOK, false},
+		{"key only, wrong key", key, false, credentials{key: "nope"}, http.StatusUnauthorized, false},
+		{"key only, no credentials", key, false, credentials{}, http.StatusUnauthorized, false},
+		{"key only, Basic ignored", key, false, credentials{user: user, pass: pass}, http.StatusUnauthorized, false},
+		{"basic only, valid Basic", "", true, credentials{user: user, pass: pass}, http.StatusOK, false},
+		{"basic only, wrong password", "", true, credentials{user: user, pass: "nope"}, http.StatusUnauthorized, true},
+		{"basic only, no credentials", "", true, credentials{}, http.StatusUnauthorized, true},
+		{"basic only, key ignored", "", true, credentials{key: key}, http.StatusUnauthorized, true},
+		{"both, valid key", key, true, credentials{key: key}, http.StatusOK, false},
+		{"both, valid Basic", key, true, credentials{user: user, pass: pass}, http.StatusOK, false},
+		{"both, wrong key with valid Basic", key, true, credentials{key: "nope", user: user, pass: pass}, http.StatusUnauthorized, false},
+		{"both, no credentials", key, true, credentials{}, http.StatusUnauthorized, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			apiCfg := &apiConfig{Store: store.NewMemory(), TimeFormat: timeFormatRFC3339, AdminKey: tt.cfgKey}
+			if tt.basic {
+				apiCfg.AdminUser, apiCfg.AdminPass = user, pass
+			}
+			adminMux := http.NewServeMux()
+			adminMux.HandleFunc("/v1/admin/users", listUsersHandler(apiCfg))
+			h := middlewareAdmin(apiCfg, adminMux)
+
+			r := httptest.NewRequest(http.MethodGet, "/v1/admin/users", nil)
+			if tt.creds.key != "" {
+				r.Header.Set("X-Admin-Key", tt.creds.key)
+			}
+			if tt.creds.user != "" {
+				r.SetBasicAuth(tt.creds.user, tt.creds.pass)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if got := w.Header().Get("WWW-Authenticate") != ""; got != tt.wantWWWAuth {
+				t.Errorf("WWW-Authenticate set: %t, want %t", got, tt.wantWWWAuth)
+			}
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+// configureHTTP2 applies the HTTP/2 and keep-alive settings from the
+// environment to server:
+//
+//   - H2C=true serves HTTP/2 over plain TCP (h2c) alongside HTTP/1.1. This
+//     suits clients that multiplex many requests over one connection, such
+//     as gRPC-style clients behind a TLS-terminating proxy, but h2c must never
+//     be exposed directly to browsers or untrusted networks since it has no
+//     encryption.
+//   - HTTP2_MAX_CONCURRENT_STREAMS caps the streams per h2c connection
+//     (default 250). Higher values help busy clients but let a single
+//     connection take a larger share of the server. The server does not
+//     terminate TLS, so h2c is its only HTTP/2 and setting this without
+//     H2C=true is a startup error.
+//   - KEEP_ALIVES=false closes HTTP/1.1 connections after each request. That
+//     spreads load more evenly behind some load balancers at the cost of a new
+//     connection per request.
+func configureHTTP2(server *http.Server) error {
+	enableH2C := false
+	if err := boolFromEnv("H2C", &enableH2C); err != nil {
+		return err
+	}
+
+	maxStreams := uint32(250)
+	if v := os.Getenv("HTTP2_MAX_CONCURRENT_STREAMS"); v != "" {
+		if !enableH2C {
+			return fmt.Errorf("HTTP2_MAX_CONCURRENT_STREAMS only applies with H2C=true")
+		}
+		n, err := strconv.ParseUint(v, 10, 32)
+		if err != nil || n == 0 {
+			return fmt.Errorf("invalid HTTP2_MAX_CONCURRENT_STREAMS %q, expected a positive integer", v)
+		}
+		maxStreams = uint32(n)
+	}
+
+	if enableH2C {
+		// Registering the HTTP/2 server with server makes Shutdown send GOAWAY
+		// on h2c connections, which are hijacked and otherwise not tracked
+		h2s := &http2.Server{
+			MaxConcurrentStreams: maxStreams,
+			IdleTimeout:          server.IdleTimeout,
+		}
+		if err := http2.ConfigureServer(server, h2s); err != nil {
+			return fmt.Errorf("error configuring HTTP/2: %w", err)
+		}
+		server.Handler = h2c.NewHandler(server.Handler, h2s)
+	}
+
+	keepAlives := true
+	if err := boolFromEnv("KEEP_ALIVES", &keepAlives); err != nil {
+		return err
+	}
+	server.SetKeepAlivesEnabled(keepAlives)
+	return nil
+}
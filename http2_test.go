@@ -0,0 +1,66 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestConfigureHTTP2(t *testing.T) {
+	tests := []struct {
+		name          string
+		env           map[string]string
+		wantErr       bool
+		wantH2C       bool
+		wantConnClose bool
+	}{
+		{name: "defaults"},
+		{name: "h2c", env: map[string]string{"H2C": "true"}, wantH2C: true},
+		{name: "h2c with streams", env: map[string]string{"H2C": "true", "HTTP2_MAX_CONCURRENT_STREAMS": "100"}, wantH2C: true},
+		{name: "streams without h2c", env: map[string]string{"HTTP2_MAX_CONCURRENT_STREAMS": "100"}, wantErr: true},
+		{name: "streams with h2c off", env: map[string]string{"H2C": "false", "HTTP2_MAX_CONCURRENT_STREAMS": "100"}, wantErr: true},
+		{name: "zero streams", env: map[string]string{"H2C": "true", "HTTP2_MAX_CONCURRENT_STREAMS": "0"}, wantErr: true},
+		{name: "invalid streams", env: map[string]string{"H2C": "true", "HTTP2_MAX_CONCURRENT_STREAMS": "lots"}, wantErr: true},
+		{name: "invalid h2c", env: map[string]string{"H2C": "maybe"}, wantErr: true},
+		{name: "keep-alives off", env: map[string]string{"KEEP_ALIVES": "false"}, wantConnClose: true},
+		{name: "invalid keep-alives", env: map[string]string{"KEEP_ALIVES": "sometimes"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, name := range []string{"H2C", "HTTP2_MAX_CONCURRENT_STREAMS", "KEEP_ALIVES"} {
+				t.Setenv(name, tt.env[name])
+			}
+			server := &http.Server{Handler: http.HandlerFunc(livenessHandler)}
+			err := configureHTTP2(server)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("configureHTTP2 succeeded, want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, ok := server.TLSNextProto["h2"]; ok != tt.wantH2C {
+				t.Errorf("HTTP/2 registered with the server: %t, want %t", ok, tt.wantH2C)
+			}
+
+			// Serve a request to see whether the connection is kept alive
+			ts := httptest.NewUnstartedServer(server.Handler)
+			ts.Config = server
+			ts.Start()
+			defer ts.Close()
+			res, err := http.Get(ts.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			res.Body.Close()
+			if res.StatusCode != http.StatusOK {
+				t.Errorf("status %d, want %d", res.StatusCode, http.StatusOK)
+			}
+			if res.Close != tt.wantConnClose {
+				t.Errorf("connection closed after response: %t, want %t", res.Close, tt.wantConnClose)
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package store
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/seanogor/blogaggregator.git/internal/database"
+)
+
+// Memory is an in-memory Store for tests and local development. It is safe
+// for concurrent use.
+type Memory struct {
+	mu    sync.Mutex
+	users []database.User
+}
+
+var _ Store = (*Memory)(nil)
+
+// NewMemory returns an empty in-memory store.
+func NewMemory() *Memory {
+	return &Memory{}
+}
+
+func (m *Memory) CreateUser(ctx context.Context, arg database.CreateUserParams) (database.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if slices.ContainsFunc(m.users, func(u database.User) bool { return u.ID == arg.ID }) {
+		return database.User{}, fmt.Errorf("%w: user %s already exists", ErrConflict, arg.ID)
+	}
+	user := database.User{
+		ID:        arg.ID,
+		CreatedAt: arg.CreatedAt,
+		UpdatedAt: arg.UpdatedAt,
+		Name:      arg.Name,
+	}
+	m.users = append(m.users, user)
+	return user, nil
+}
+
+func (m *Memory) ListUsers(ctx context.Context, arg database.ListUsersParams) ([]database.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// Match the Postgres query's ORDER BY created_at, id
+	users := slices.Clone(m.users)
+	slices.SortFunc(users, func(a, b database.User) int {
+		if c := a.CreatedAt.Compare(b.CreatedAt); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.ID.String(), b.ID.String())
+	})
+
+	// Postgres rejects negative values; treat them as zero rather than panic
+	start := min(max(int(arg.Offset), 0), len(users))
+	end := min(start+max(int(arg.Limit), 0), len(users))
+	return users[start:end], nil
+}
+
+func (m *Memory) CountUsers(ctx context.Context) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return int64(len(m.users)), nil
+}
+
+func (m *Memory) Ping(ctx context.Context) error {
+	return nil
+}
@@ -0,0 +1,54 @@
+// Package store defines the storage interface the HTTP handlers depend on,
+// so they are not tied to Postgres.
+package store
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/seanogor/blogaggregator.git/internal/database"
+)
+
+// Store is the set of storage operations used by the handlers. Errors can be
+// checked against ErrNotFound, ErrConflict and ErrForbidden.
+type Store interface {
+	CreateUser(ctx context.Context, arg database.CreateUserParams) (database.User, error)
+	ListUsers(ctx context.Context, arg database.ListUsersParams) ([]database.User, error)
+	CountUsers(ctx context.Context) (int64, error)
+
+	// Ping checks that the store is reachable.
+	Ping(ctx context.Context) error
+}
+
+// postgres is the Store backed by the sqlc queries, translating Postgres
+// errors into Store errors.
+type postgres struct {
+	db      *sql.DB
+	queries *database.Queries
+}
+
+var _ Store = (*postgres)(nil)
+
+// NewPostgres returns a Store backed by the given database connection.
+func NewPostgres(db *sql.DB) Store {
+	return &postgres{db: db, queries: database.New(db)}
+}
+
+func (p *postgres) CreateUser(ctx context.Context, arg database.CreateUserParams) (database.User, error) {
+	user, err := p.queries.CreateUser(ctx, arg)
+	return user, wrapError(err)
+}
+
+func (p *postgres) ListUsers(ctx context.Context, arg database.ListUsersParams) ([]database.User, error) {
+	users, err := p.queries.ListUsers(ctx, arg)
+	return users, wrapError(err)
+}
+
+func (p *postgres) CountUsers(ctx context.Context) (int64, error) {
+	count, err := p.queries.CountUsers(ctx)
+	return count, wrapError(err)
+}
+
+func (p *postgres) Ping(ctx context.Context) error {
+	return p.db.PingContext(ctx)
+}
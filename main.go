@@ -1,97 +1,370 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
+	"log/slog"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
+	"github.com/seanogor/blogaggregator.git/internal/database"
+	"github.com/seanogor/blogaggregator.git/internal/store"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 )
 
 type apiConfig struct {
-	DB *sql.DB // Change the type to *sql.DB
+	Store      store.Store
+	TimeFormat timeFormat
+	AdminKey   string
+	AdminUser  string
+	AdminPass  string
+
+	// Maintenance is set while the API is in maintenance mode
+	Maintenance atomic.Bool
+
+	// Started is set once startup has completed, see markStartedWhenReady
+	Started atomic.Bool
 }
 
 func main() {
+	if err := run(); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
+
+// run starts the server, or runs migrations with -migrate, returning any error
+// that should make the process exit non-zero. Errors are returned rather than
+// exiting in place so deferred cleanup still runs.
+func run() error {
+	// Parse command-line flags
+	migrate := flag.String("migrate", "", "apply database migrations (up or down) and exit")
+	flag.Parse()
+
 	// Load environment variables
 	err := godotenv.Load()
 	if err != nil {
 		fmt.Println("Error loading .env file")
 	}
 
+	// Set up the request logger in the format from environment variable
+	logger, err := newLogger(os.Getenv("LOG_FORMAT"))
+	if err != nil {
+		return fmt.Errorf("invalid LOG_FORMAT: %w", err)
+	}
+	slog.SetDefault(logger)
+
 	// Get the database URL from environment variable
 	dbURL := os.Getenv("DATABASE_URL")
 	if dbURL == "" {
-		fmt.Println("DATABASE_URL not found in environment variables")
-		return
+		return errors.New("DATABASE_URL not found in environment variables")
 	}
 
 	// Open a connection to the database
 	db, err := sql.Open("postgres", dbURL)
 	if err != nil {
-		fmt.Printf("Error connecting to the database: %s\n", err)
-		return
+		return fmt.Errorf("error connecting to the database: %w", err)
 	}
 	defer db.Close()
 
-	// Create a database queries instance
-	dbQueries := database.New(db)
+	// Run migrations instead of serving when asked to
+	if *migrate != "" {
+		if err := runMigrations(db, *migrate); err != nil {
+			return fmt.Errorf("error running migrations: %w", err)
+		}
+		return nil
+	}
+
+	// Set up tracing; this is a no-op unless an OTLP endpoint is configured
+	shutdownTracing, err := setupTracing(context.Background())
+	if err != nil {
+		return fmt.Errorf("error setting up tracing: %w", err)
+	}
+	defer shutdownTracing(context.Background())
+
+	// Get the default response time format from environment variable or default to RFC3339Nano
+	defaultTimeFormat := timeFormatRFC3339Nano
+	if v := os.Getenv("TIME_FORMAT"); v != "" {
+		defaultTimeFormat, err = parseTimeFormat(v)
+		if err != nil {
+			return fmt.Errorf("invalid TIME_FORMAT: %w", err)
+		}
+	}
 
 	// Create an instance of apiConfig and store the database connection
 	apiCfg := &apiConfig{
-		DB: db,
+		Store:      store.NewPostgres(db),
+		TimeFormat: defaultTimeFormat,
+		AdminKey:   os.Getenv("ADMIN_API_KEY"),
+		AdminUser:  os.Getenv("ADMIN_USER"),
+		AdminPass:  os.Getenv("ADMIN_PASS"),
+	}
+	if (apiCfg.AdminUser == "") != (apiCfg.AdminPass == "") {
+		return errors.New("ADMIN_USER and ADMIN_PASS must be set together")
 	}
 
+	// Start in maintenance mode if asked to by environment variable
+	startInMaintenance := false
+	if err := boolFromEnv("MAINTENANCE_MODE", &startInMaintenance); err != nil {
+		return err
+	}
+	apiCfg.setMaintenance(startInMaintenance)
+
 	// Get the port from environment variable or default to 8080
 	port := os.Getenv("PORT")
 	if port == "" {
 		port = "8080"
 	}
 
+	// Get the listen address from environment variable or default to all interfaces
+	listenAddr := os.Getenv("LISTEN_ADDR")
+	if listenAddr == "" {
+		listenAddr = "0.0.0.0"
+	}
+
+	// Combine the listen address and port, failing fast if the result is malformed
+	addr := net.JoinHostPort(listenAddr, port)
+	if _, err := net.ResolveTCPAddr("tcp", addr); err != nil {
+		return fmt.Errorf("invalid listen address %q: %w", addr, err)
+	}
+
+	// Get the shutdown drain and connection timeouts from environment variables.
+	// The defaults bound slow clients: 5s to send headers, 15s for the whole
+	// request, 30s to write the response and 2m for idle keep-alive connections.
+	shutdownTimeout := 10 * time.Second
+	readHeaderTimeout := 5 * time.Second
+	readTimeout := 15 * time.Second
+	writeTimeout := 30 * time.Second
+	idleTimeout := 2 * time.Minute
+	for _, timeout := range []struct {
+		env string
+		d   *time.Duration
+	}{
+		{"SHUTDOWN_TIMEOUT", &shutdownTimeout},
+		{"READ_HEADER_TIMEOUT", &readHeaderTimeout},
+		{"READ_TIMEOUT", &readTimeout},
+		{"WRITE_TIMEOUT", &writeTimeout},
+		{"IDLE_TIMEOUT", &idleTimeout},
+	} {
+		if err := durationFromEnv(timeout.env, timeout.d); err != nil {
+			return err
+		}
+	}
+
 	// Create a ServeMux
 	mux := http.NewServeMux()
 
-	// Add CORS middleware
-	mux.HandleFunc("/", middlewareCors(rootHandler))
+	// Add a root handler
+	mux.HandleFunc("/", rootHandler)
 
 	// Add a handler to create a user
 	mux.HandleFunc("/v1/users", createUserHandler(apiCfg))
 
+	// Create a ServeMux for admin routes, all guarded by the admin middleware
+	adminMux := http.NewServeMux()
+	mux.Handle("/v1/admin/", middlewareAdmin(apiCfg, adminMux))
+
+	// Add an admin handler to list users
+	adminMux.HandleFunc("/v1/admin/users", listUsersHandler(apiCfg))
+
+	// Add an admin handler to view and toggle maintenance mode
+	adminMux.HandleFunc("/v1/admin/maintenance", maintenanceHandler(apiCfg))
+
 	// Add a readiness handler
-	mux.HandleFunc("/v1/readiness", readinessHandler)
+	mux.HandleFunc("/v1/readiness", readinessHandler(apiCfg))
+
+	// Add startup and liveness probe handlers
+	mux.HandleFunc("/v1/startupz", startupHandler(apiCfg))
+	mux.HandleFunc("/v1/livez", livenessHandler)
 
 	// Add an error handler
 	mux.HandleFunc("/v1/err", errorHandler)
 
+	// Wrap the mux in middleware, innermost first, counting requests in flight
+	// so shutdown can report them
+	var inFlight atomic.Int64
+	var handler http.Handler = middlewareMaintenance(apiCfg, mux.ServeHTTP)
+	handler = middlewareCors(handler.ServeHTTP)
+	handler = middlewareRecoverer(logger, handler)
+	handler = middlewareLogger(logger, handler)
+	handler = middlewareRequestID(handler)
+	handler = middlewareInFlight(&inFlight, handler)
+	handler = otelhttp.NewHandler(handler, serviceName,
+		otelhttp.WithSpanNameFormatter(routeSpanNames(mux, adminMux)),
+	)
+
 	// Create an HTTP server
 	server := &http.Server{
-		Addr:    ":" + port,
-		Handler: mux,
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: readHeaderTimeout,
+		ReadTimeout:       readTimeout,
+		WriteTimeout:      writeTimeout,
+		IdleTimeout:       idleTimeout,
+	}
+	if err := configureHTTP2(server); err != nil {
+		return err
 	}
 
-	// Start the server
-	fmt.Printf("Server listening on port %s\n", port)
-	err = server.ListenAndServe()
+	// Listen before serving so the startup probe only passes once the server is bound
+	listener, err := net.Listen("tcp", addr)
 	if err != nil {
-		fmt.Printf("Error starting server: %s\n", err)
+		return fmt.Errorf("error starting server: %w", err)
+	}
+
+	// Start the server in the background
+	fmt.Printf("Server listening on %s\n", addr)
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- server.Serve(listener)
+	}()
+
+	// Mark startup complete once the database is reachable, giving up on shutdown
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go markStartedWhenReady(ctx, apiCfg, db)
+
+	// Wait for an interrupt or termination signal, or for the server to fail
+	select {
+	case err := <-serverErr:
+		return fmt.Errorf("error starting server: %w", err)
+	case <-ctx.Done():
 	}
+
+	// Drain in-flight requests, cutting off whatever is left after the shutdown timeout
+	fmt.Printf("Shutting down, draining %d in-flight requests (timeout %s)\n", inFlight.Load(), shutdownTimeout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		server.Close()
+		return fmt.Errorf("shutdown timed out, cutting off %d in-flight requests: %w", inFlight.Load(), err)
+	}
+
+	// Shutdown does not wait for requests on hijacked connections such as h2c,
+	// so wait for the in-flight count to reach zero within the same timeout
+	if err := waitForDrain(shutdownCtx, &inFlight); err != nil {
+		server.Close()
+		return fmt.Errorf("shutdown timed out, cutting off %d in-flight requests: %w", inFlight.Load(), err)
+	}
+	fmt.Println("Server stopped, all requests drained")
+	return nil
+}
+
+// durationFromEnv overwrites *d with the duration in the named environment
+// variable, if set. The value must be a positive Go duration such as "30s".
+func durationFromEnv(name string, d *time.Duration) error {
+	v := os.Getenv(name)
+	if v == "" {
+		return nil
+	}
+	parsed, err := time.ParseDuration(v)
+	if err != nil || parsed <= 0 {
+		return fmt.Errorf("invalid %s %q, expected a positive duration such as 30s", name, v)
+	}
+	*d = parsed
+	return nil
 }
 
+// boolFromEnv overwrites *b with the boolean in the named environment
+// variable, if set.
+func boolFromEnv(name string, b *bool) error {
+	v := os.Getenv(name)
+	if v == "" {
+		return nil
+	}
+	parsed, err := strconv.ParseBool(v)
+	if err != nil {
+		return fmt.Errorf("invalid %s %q, expected true or false", name, v)
+	}
+	*b = parsed
+	return nil
+}
+
+// waitForDrain polls inFlight until no requests are left, returning the
+// context's error if it is done first.
+func waitForDrain(ctx context.Context, inFlight *atomic.Int64) error {
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+	for inFlight.Load() > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+	return nil
+}
+
+// middlewareInFlight keeps count of the requests currently being handled.
+func middlewareInFlight(inFlight *atomic.Int64, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		inFlight.Add(1)
+		defer inFlight.Add(-1)
+		next.ServeHTTP(w, r)
+	})
+}
+
+var (
+	corsAllowedMethods = []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"}
+	corsAllowedHeaders = []string{"Accept", "Authorization", "Content-Type", "X-Admin-Key", "X-Request-ID"}
+)
+
+// corsMaxAge is how long, in seconds, browsers may cache a preflight response.
+const corsMaxAge = "3600"
+
 func middlewareCors(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS, PUT, DELETE")
-		w.Header().Set("Access-Control-Allow-Headers", "*")
-		if r.Method == "OPTIONS" {
-			w.WriteHeader(http.StatusOK)
+		if r.Method != "OPTIONS" {
+			next.ServeHTTP(w, r)
 			return
 		}
-		next.ServeHTTP(w, r)
+
+		// Plain OPTIONS requests just get the supported methods
+		requestedMethod := r.Header.Get("Access-Control-Request-Method")
+		if requestedMethod == "" {
+			w.Header().Set("Allow", strings.Join(corsAllowedMethods, ", "))
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		// Preflight requests only get back the method and headers we allow
+		w.Header().Add("Vary", "Access-Control-Request-Method")
+		w.Header().Add("Vary", "Access-Control-Request-Headers")
+		if !slices.Contains(corsAllowedMethods, strings.ToUpper(requestedMethod)) {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		w.Header().Set("Access-Control-Allow-Methods", strings.ToUpper(requestedMethod))
+		var allowedHeaders []string
+		for _, header := range strings.Split(r.Header.Get("Access-Control-Request-Headers"), ",") {
+			header = strings.TrimSpace(header)
+			if slices.ContainsFunc(corsAllowedHeaders, func(allowed string) bool {
+				return strings.EqualFold(allowed, header)
+			}) {
+				allowedHeaders = append(allowedHeaders, header)
+			}
+		}
+		if len(allowedHeaders) > 0 {
+			w.Header().Set("Access-Control-Allow-Headers", strings.Join(allowedHeaders, ", "))
+		}
+		w.Header().Set("Access-Control-Max-Age", corsMaxAge)
+		w.WriteHeader(http.StatusNoContent)
 	}
 }
 
@@ -117,35 +390,66 @@ func createUserHandler(apiCfg *apiConfig) http.HandlerFunc {
 		currentTime := time.Now().UTC()
 
 		// Insert the user into the database
-		_, err = apiCfg.DB.Exec("INSERT INTO users (id, created_at, updated_at, name) VALUES ($1, $2, $3, $4)",
-			userID, currentTime, currentTime, user.Name)
+		ctx, span := tracer.Start(r.Context(), "db.CreateUser")
+		created, err := apiCfg.Store.CreateUser(ctx, database.CreateUserParams{
+			ID:        userID,
+			CreatedAt: currentTime,
+			UpdatedAt: currentTime,
+			Name:      user.Name,
+		})
+		span.End()
 		if err != nil {
-			respondWithError(w, http.StatusInternalServerError, "Failed to create user")
+			respondWithError(w, mapErrorToStatus(err), "Failed to create user")
 			return
 		}
 
 		// Respond with the created user
-		respondWithJSON(w, http.StatusCreated, map[string]interface{}{
-			"id":         userID,
-			"created_at": currentTime,
-			"updated_at": currentTime,
-			"name":       user.Name,
-		})
+		respondWithJSON(w, http.StatusCreated, userResponse(created, apiCfg.requestTimeFormat(w, r)))
+	}
+}
+
+// userResponse is the JSON shape of a user returned by the API.
+func userResponse(user database.User, format timeFormat) map[string]interface{} {
+	return map[string]interface{}{
+		"id":         user.ID,
+		"created_at": jsonTime{user.CreatedAt, format},
+		"updated_at": jsonTime{user.UpdatedAt, format},
+		"name":       user.Name,
 	}
 }
 
 func respondWithJSON(w http.ResponseWriter, status int, payload interface{}) {
+	// Marshal up front so Content-Length is set, which also keeps it on HEAD responses
+	data, err := json.Marshal(payload)
+	if err != nil {
+		slog.Error("error marshalling JSON response", slog.Any("error", err))
+		respondWithError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	// End with a newline, as json.Encoder does
+	data = append(data, '\n')
 	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
 	w.WriteHeader(status)
-	json.NewEncoder(w).Encode(payload)
+	w.Write(data)
 }
 
-func respondWithError(w http.ResponseWriter, code int, msg string) {
-	respondWithJSON(w, code, map[string]string{"error": msg})
+// mapErrorToStatus picks the HTTP status for an error returned by the store.
+func mapErrorToStatus(err error) int {
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, store.ErrConflict):
+		return http.StatusConflict
+	case errors.Is(err, store.ErrForbidden):
+		return http.StatusForbidden
+	default:
+		return http.StatusInternalServerError
+	}
 }
 
-func readinessHandler(w http.ResponseWriter, r *http.Request) {
-	respondWithJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+func respondWithError(w http.ResponseWriter, code int, msg string) {
+	respondWithJSON(w, code, map[string]string{"error": msg})
 }
 
 func errorHandler(w http.ResponseWriter, r *http.Request) {
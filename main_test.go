@@ -0,0 +1,279 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/seanogor/blogaggregator.git/internal/database"
+	"github.com/seanogor/blogaggregator.git/internal/store"
+)
+
+func TestReadinessHeadMatchesGet(t *testing.T) {
+	apiCfg := &apiConfig{Store: store.NewMemory()}
+	server := httptest.NewServer(readinessHandler(apiCfg))
+	defer server.Close()
+
+	responses := map[string]*http.Response{}
+	bodies := map[string][]byte{}
+	for _, method := range []string{http.MethodGet, http.MethodHead} {
+		req, err := http.NewRequest(method, server.URL+"/v1/readiness", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := io.ReadAll(res.Body)
+		res.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		responses[method], bodies[method] = res, body
+	}
+
+	get, head := responses[http.MethodGet], responses[http.MethodHead]
+	if get.StatusCode != http.StatusOK || head.StatusCode != get.StatusCode {
+		t.Errorf("status: GET %d, HEAD %d, want both %d", get.StatusCode, head.StatusCode, http.StatusOK)
+	}
+	for _, header := range []string{"Content-Type", "Content-Length"} {
+		if got, want := head.Header.Get(header), get.Header.Get(header); got != want || want == "" {
+			t.Errorf("%s: HEAD %q, GET %q, want equal and set", header, got, want)
+		}
+	}
+	if len(bodies[http.MethodGet]) == 0 {
+		t.Error("GET returned an empty body")
+	}
+	if len(bodies[http.MethodHead]) != 0 {
+		t.Errorf("HEAD returned a body: %q", bodies[http.MethodHead])
+	}
+}
+
+func TestCreateUserHandler(t *testing.T) {
+	apiCfg := &apiConfig{Store: store.NewMemory(), TimeFormat: timeFormatRFC3339}
+
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+	}{
+		{"valid", `{"name":"alice"}`, http.StatusCreated},
+		{"invalid json", `{"name":`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPost, "/v1/users", strings.NewReader(tt.body))
+			createUserHandler(apiCfg)(w, r)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+		})
+	}
+
+	// Only the valid request was stored
+	users, err := apiCfg.Store.ListUsers(context.Background(), database.ListUsersParams{Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(users) != 1 || users[0].Name != "alice" {
+		t.Fatalf("stored users %+v, want one named alice", users)
+	}
+}
+
+func TestMapErrorToStatus(t *testing.T) {
+	// A duplicate ID makes the memory store report a conflict, as Postgres does
+	s := store.NewMemory()
+	params := database.CreateUserParams{ID: uuid.New(), Name: "alice"}
+	if _, err := s.CreateUser(context.Background(), params); err != nil {
+		t.Fatal(err)
+	}
+	_, conflictErr := s.CreateUser(context.Background(), params)
+
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"conflict", conflictErr, http.StatusConflict},
+		{"not found", fmt.Errorf("lookup: %w", store.ErrNotFound), http.StatusNotFound},
+		{"forbidden", store.ErrForbidden, http.StatusForbidden},
+		{"other", errors.New("boom"), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := mapErrorToStatus(tt.err); got != tt.want {
+				t.Errorf("mapErrorToStatus(%v) = %d, want %d", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWaitForDrain(t *testing.T) {
+	var inFlight atomic.Int64
+	inFlight.Store(1)
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		inFlight.Add(-1)
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := waitForDrain(ctx, &inFlight); err != nil {
+		t.Fatalf("waitForDrain: %v, want nil once requests finish", err)
+	}
+
+	// A request that never finishes runs into the timeout
+	inFlight.Store(1)
+	ctx, cancel = context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := waitForDrain(ctx, &inFlight); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("waitForDrain: %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestMiddlewareCors(t *testing.T) {
+	tests := []struct {
+		name        string
+		method      string
+		headers     map[string]string
+		wantStatus  int
+		wantHeaders map[string]string
+		wantVary    []string
+		wantNext    bool
+	}{
+		{
+			name:        "non-OPTIONS passes through",
+			method:      http.MethodGet,
+			wantStatus:  http.StatusOK,
+			wantHeaders: map[string]string{"Access-Control-Allow-Origin": "*", "Access-Control-Allow-Methods": ""},
+			wantNext:    true,
+		},
+		{
+			name:        "plain OPTIONS",
+			method:      http.MethodOptions,
+			wantStatus:  http.StatusNoContent,
+			wantHeaders: map[string]string{"Allow": "GET, POST, PUT, DELETE, OPTIONS", "Access-Control-Allow-Methods": "", "Access-Control-Max-Age": ""},
+		},
+		{
+			name:       "preflight",
+			method:     http.MethodOptions,
+			headers:    map[string]string{"Access-Control-Request-Method": "POST", "Access-Control-Request-Headers": "Content-Type"},
+			wantStatus: http.StatusNoContent,
+			wantHeaders: map[string]string{
+				"Access-Control-Allow-Methods": "POST",
+				"Access-Control-Allow-Headers": "Content-Type",
+				"Access-Control-Max-Age":       corsMaxAge,
+				"Allow":                        "",
+			},
+			wantVary: []string{"Access-Control-Request-Method", "Access-Control-Request-Headers"},
+		},
+		{
+			name:        "lowercase method is normalized",
+			method:      http.MethodOptions,
+			headers:     map[string]string{"Access-Control-Request-Method": "put"},
+			wantStatus:  http.StatusNoContent,
+			wantHeaders: map[string]string{"Access-Control-Allow-Methods": "PUT", "Access-Control-Allow-Headers": ""},
+			wantVary:    []string{"Access-Control-Request-Method", "Access-Control-Request-Headers"},
+		},
+		{
+			name:       "disallowed method",
+			method:     http.MethodOptions,
+			headers:    map[string]string{"Access-Control-Request-Method": "PATCH", "Access-Control-Request-Headers": "Content-Type"},
+			wantStatus: http.StatusNoContent,
+			wantHeaders: map[string]string{
+				"Access-Control-Allow-Methods": "",
+				"Access-Control-Allow-Headers": "",
+				"Access-Control-Max-Age":       "",
+			},
+			wantVary: []string{"Access-Control-Request-Method", "Access-Control-Request-Headers"},
+		},
+		{
+			name:        "only allowed headers are echoed",
+			method:      http.MethodOptions,
+			headers:     map[string]string{"Access-Control-Request-Method": "POST", "Access-Control-Request-Headers": "content-type, x-foo"},
+			wantStatus:  http.StatusNoContent,
+			wantHeaders: map[string]string{"Access-Control-Allow-Headers": "content-type"},
+		},
+		{
+			name:        "no allowed headers",
+			method:      http.MethodOptions,
+			headers:     map[string]string{"Access-Control-Request-Method": "POST", "Access-Control-Request-Headers": "x-foo"},
+			wantStatus:  http.StatusNoContent,
+			wantHeaders: map[string]string{"Access-Control-Allow-Methods": "POST", "Access-Control-Allow-Headers": ""},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nextCalled := false
+			h := middlewareCors(func(w http.ResponseWriter, r *http.Request) {
+				nextCalled = true
+			})
+			r := httptest.NewRequest(tt.method, "/v1/users", nil)
+			for name, value := range tt.headers {
+				r.Header.Set(name, value)
+			}
+			w := httptest.NewRecorder()
+			h(w, r)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("status %d, want %d", w.Code, tt.wantStatus)
+			}
+			if nextCalled != tt.wantNext {
+				t.Errorf("next handler called: %t, want %t", nextCalled, tt.wantNext)
+			}
+			for name, want := range tt.wantHeaders {
+				if got := w.Header().Get(name); got != want {
+					t.Errorf("%s: %q, want %q", name, got, want)
+				}
+			}
+			if tt.wantVary != nil {
+				if got := w.Header().Values("Vary"); !slices.Equal(got, tt.wantVary) {
+					t.Errorf("Vary: %q, want %q", got, tt.wantVary)
+				}
+			}
+		})
+	}
+}
+
+func TestRespondWithJSON(t *testing.T) {
+	w := httptest.NewRecorder()
+	respondWithJSON(w, http.StatusCreated, map[string]string{"status": "ok"})
+	if w.Code != http.StatusCreated {
+		t.Errorf("status %d, want %d", w.Code, http.StatusCreated)
+	}
+	if got, want := w.Body.String(), "{\"status\":\"ok\"}\n"; got != want {
+		t.Errorf("body %q, want %q", got, want)
+	}
+	if got, want := w.Header().Get("Content-Length"), strconv.Itoa(w.Body.Len()); got != want {
+		t.Errorf("Content-Length %s, want %s", got, want)
+	}
+
+	// A payload that can't be marshalled is logged and becomes the standard error
+	var logs bytes.Buffer
+	defer slog.SetDefault(slog.Default())
+	slog.SetDefault(slog.New(slog.NewTextHandler(&logs, nil)))
+	w = httptest.NewRecorder()
+	respondWithJSON(w, http.StatusOK, map[string]any{"ch": make(chan int)})
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("status %d, want %d", w.Code, http.StatusInternalServerError)
+	}
+	if got, want := w.Body.String(), "{\"error\":\"Internal Server Error\"}\n"; got != want {
+		t.Errorf("body %q, want %q", got, want)
+	}
+	if !strings.Contains(logs.String(), "error marshalling JSON response") {
+		t.Errorf("marshal error not logged: %q", logs.String())
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// healthPaths are the probe endpoints that keep answering in maintenance mode.
+var healthPaths = []string{"/v1/readiness", "/v1/startupz", "/v1/livez"}
+
+// maintenanceRetryAfter is the Retry-After, in seconds, sent while in
+// maintenance mode.
+const maintenanceRetryAfter = "120"
+
+// setMaintenance switches maintenance mode and logs the change.
+func (cfg *apiConfig) setMaintenance(enabled bool) {
+	if cfg.Maintenance.Swap(enabled) == enabled {
+		return
+	}
+	if enabled {
+		fmt.Println("Entering maintenance mode")
+	} else {
+		fmt.Println("Leaving maintenance mode")
+	}
+}
+
+// maintenanceExempt reports whether path keeps answering in maintenance mode:
+// the admin API, including the bare /v1/admin that the mux redirects to
+// /v1/admin/, and the health probes.
+func maintenanceExempt(path string) bool {
+	return path == "/v1/admin" || strings.HasPrefix(path, "/v1/admin/") || slices.Contains(healthPaths, path)
+}
+
+// middlewareMaintenance answers 503 for every route except the admin and
+// health probe endpoints while maintenance mode is on.
+func middlewareMaintenance(apiCfg *apiConfig, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if apiCfg.Maintenance.Load() && !maintenanceExempt(r.URL.Path) {
+			w.Header().Set("Retry-After", maintenanceRetryAfter)
+			respondWithError(w, http.StatusServiceUnavailable, "Service is down for maintenance")
+			return
+		}
+		next.ServeHTTP(w, r)
+	}
+}
+
+// maintenanceHandler reports maintenance mode on GET and HEAD and sets it from
+// an {"enabled": bool} body on POST and PUT.
+func maintenanceHandler(apiCfg *apiConfig) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead:
+		case http.MethodPost, http.MethodPut:
+			var params struct {
+				Enabled *bool `json:"enabled"`
+			}
+			err := json.NewDecoder(r.Body).Decode(&params)
+			if err != nil || params.Enabled == nil {
+				respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+				return
+			}
+			apiCfg.setMaintenance(*params.Enabled)
+		default:
+			w.Header().Set("Allow", "GET, HEAD, POST, PUT")
+			respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+			return
+		}
+		respondWithJSON(w, http.StatusOK, map[string]bool{"enabled": apiCfg.Maintenance.Load()})
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMiddlewareMaintenance(t *testing.T) {
+	apiCfg := &apiConfig{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", rootHandler)
+	mux.HandleFunc("/v1/admin/", livenessHandler)
+	h := middlewareMaintenance(apiCfg, mux.ServeHTTP)
+
+	tests := []struct {
+		path       string
+		wantStatus int
+	}{
+		{"/v1/users", http.StatusServiceUnavailable},
+		{"/", http.StatusServiceUnavailable},
+		{"/v1/administrator", http.StatusServiceUnavailable},
+		{"/v1/readiness/extra", http.StatusServiceUnavailable},
+		{"/v1/admin", http.StatusTemporaryRedirect},
+		{"/v1/admin/maintenance", http.StatusOK},
+		{"/v1/readiness", http.StatusOK},
+		{"/v1/startupz", http.StatusOK},
+		{"/v1/livez", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			apiCfg.Maintenance.Store(true)
+			w := httptest.NewRecorder()
+			h(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status %d, want %d", w.Code, tt.wantStatus)
+			}
+			if gotRetry := w.Header().Get("Retry-After") != ""; gotRetry != (tt.wantStatus == http.StatusServiceUnavailable) {
+				t.Errorf("Retry-After %q on a %d response", w.Header().Get("Retry-After"), w.Code)
+			}
+
+			// Everything passes through with maintenance mode off
+			apiCfg.Maintenance.Store(false)
+			w = httptest.NewRecorder()
+			h(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			if w.Code == http.StatusServiceUnavailable {
+				t.Errorf("status %d with maintenance mode off", w.Code)
+			}
+		})
+	}
+}
+
+func TestMaintenanceHandler(t *testing.T) {
+	apiCfg := &apiConfig{}
+	tests := []struct {
+		name        string
+		method      string
+		body        string
+		wantStatus  int
+		wantEnabled bool
+	}{
+		{"get reports off", http.MethodGet, "", http.StatusOK, false},
+		{"post enables", http.MethodPost, `{"enabled":true}`, http.StatusOK, true},
+		{"get reports on", http.MethodGet, "", http.StatusOK, true},
+		{"head reports on", http.MethodHead, "", http.StatusOK, true},
+		{"delete is not allowed", http.MethodDelete, `{"enabled":false}`, http.StatusMethodNotAllowed, true},
+		{"patch is not allowed", http.MethodPatch, `{"enabled":false}`, http.StatusMethodNotAllowed, true},
+		{"missing enabled", http.MethodPut, `{}`, http.StatusBadRequest, true},
+		{"invalid json", http.MethodPut, `{"enabled":`, http.StatusBadRequest, true},
+		{"put disables", http.MethodPut, `{"enabled":false}`, http.StatusOK, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(tt.method, "/v1/admin/maintenance", strings.NewReader(tt.body))
+			maintenanceHandler(apiCfg)(w, r)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if got := apiCfg.Maintenance.Load(); got != tt.wantEnabled {
+				t.Errorf("maintenance mode %t, want %t", got, tt.wantEnabled)
+			}
+			switch {
+			case w.Code == http.StatusMethodNotAllowed:
+				if w.Header().Get("Allow") == "" {
+					t.Error("405 without an Allow header")
+				}
+			case w.Code == http.StatusOK && tt.method != http.MethodHead:
+				var body struct{ Enabled bool }
+				if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil || body.Enabled != tt.wantEnabled {
+					t.Errorf("body %q, want enabled %t", w.Body, tt.wantEnabled)
+				}
+			}
+		})
+	}
+}
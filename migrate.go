@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+
+	"github.com/lib/pq"
+	"github.com/pressly/goose/v3"
+)
+
+//go:embed sql/schema/*.sql
+var migrations embed.FS
+
+const migrationsDir = "sql/schema"
+
+// runMigrations applies ("up") or rolls back the latest ("down") of the goose
+// migrations in sql/schema, which are embedded in the binary. goose logs each
+// migration as it is applied.
+func runMigrations(db *sql.DB, direction string) error {
+	goose.SetBaseFS(migrations)
+	if err := goose.SetDialect("postgres"); err != nil {
+		return err
+	}
+
+	switch direction {
+	case "up":
+		return goose.Up(db, migrationsDir)
+	case "down":
+		return goose.Down(db, migrationsDir)
+	}
+	return fmt.Errorf("unknown migration direction %q, expected up or down", direction)
+}
+
+// latestMigration returns the version of the newest embedded migration, or 0
+// if there are none.
+func latestMigration() (int64, error) {
+	files, err := fs.Glob(migrations, migrationsDir+"/*.sql")
+	if err != nil {
+		return 0, err
+	}
+	var latest int64
+	for _, file := range files {
+		version, err := goose.NumericComponent(file)
+		if err != nil {
+			return 0, err
+		}
+		latest = max(latest, version)
+	}
+	return latest, nil
+}
+
+// migrationsCurrent reports whether the database has migration latest applied.
+// A database that is further ahead, as during a rollout where a newer release
+// migrated first, also counts as current. It only reads goose's version table,
+// unlike goose's own version lookups, which create the table when it is
+// missing; a missing table means no migrations have been applied yet.
+func migrationsCurrent(ctx context.Context, db *sql.DB, latest int64) (bool, error) {
+	if latest == 0 {
+		return true, nil
+	}
+	var version sql.NullInt64
+	err := db.QueryRowContext(ctx, "SELECT MAX(version_id) FROM "+goose.TableName()+" WHERE is_applied").Scan(&version)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "42P01" { // undefined_table
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return version.Valid && version.Int64 >= latest, nil
+}
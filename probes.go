@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// probeTimeout bounds each database check made by the probes, so a dead
+// connection can't hang them.
+const probeTimeout = 2 * time.Second
+
+// markStartedWhenReady flips apiCfg.Started once the database answers a ping
+// and has the newest embedded migration applied, retrying every second until
+// it does or ctx is done. It is run after the server is listening, so a
+// started server is able to serve requests. Failed checks are logged when the
+// error changes rather than on every retry.
+func markStartedWhenReady(ctx context.Context, apiCfg *apiConfig, db *sql.DB) {
+	latest, err := latestMigration()
+	if err != nil {
+		fmt.Printf("Error reading embedded migrations: %s\n", err)
+		return
+	}
+
+	lastErr, loggedPending := "", false
+	for {
+		attemptCtx, cancel := context.WithTimeout(ctx, probeTimeout)
+		err := db.PingContext(attemptCtx)
+		if err != nil {
+			err = fmt.Errorf("database ping failed: %w", err)
+		}
+		current := false
+		if err == nil {
+			current, err = migrationsCurrent(attemptCtx, db, latest)
+			if err != nil {
+				err = fmt.Errorf("checking migration version failed: %w", err)
+			}
+		}
+		cancel()
+
+		switch {
+		case err == nil && current:
+			apiCfg.Started.Store(true)
+			fmt.Println("Startup complete")
+			return
+		case err == nil && !loggedPending:
+			fmt.Println("Waiting for database migrations to be applied")
+			loggedPending = true
+		case err != nil && err.Error() != lastErr && ctx.Err() == nil:
+			fmt.Printf("Startup check: %s\n", err)
+			lastErr = err.Error()
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+// startupHandler is the startup probe: 503 until startup has completed.
+func startupHandler(apiCfg *apiConfig) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !apiCfg.Started.Load() {
+			respondWithError(w, http.StatusServiceUnavailable, "Starting up")
+			return
+		}
+		respondWithJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	}
+}
+
+// livenessHandler is the liveness probe: it only shows the process can still
+// answer requests.
+func livenessHandler(w http.ResponseWriter, r *http.Request) {
+	respondWithJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// readinessHandler is the readiness probe: 503 unless the database answers a
+// ping within probeTimeout.
+func readinessHandler(apiCfg *apiConfig) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), probeTimeout)
+		defer cancel()
+		if err := apiCfg.Store.Ping(ctx); err != nil {
+			respondWithError(w, http.StatusServiceUnavailable, "Database unavailable")
+			return
+		}
+		respondWithJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	}
+}
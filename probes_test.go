@@ -0,0 +1,47 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestStartupHandler(t *testing.T) {
+	apiCfg := &apiConfig{}
+	for _, tt := range []struct {
+		name       string
+		started    bool
+		wantStatus int
+	}{
+		{"not started", false, http.StatusServiceUnavailable},
+		{"started", true, http.StatusOK},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			apiCfg.Started.Store(tt.started)
+			w := httptest.NewRecorder()
+			startupHandler(apiCfg)(w, httptest.NewRequest(http.MethodGet, "/v1/startupz", nil))
+			if w.Code != tt.wantStatus {
+				t.Errorf("status %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+		})
+	}
+}
+
+func TestLivenessHandler(t *testing.T) {
+	w := httptest.NewRecorder()
+	livenessHandler(w, httptest.NewRequest(http.MethodGet, "/v1/livez", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("status %d, want %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+}
+
+func TestLatestMigration(t *testing.T) {
+	latest, err := latestMigration()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// sql/schema starts at 001_users.sql, so there is always a version
+	if latest < 1 {
+		t.Errorf("latest migration %d, want at least 1", latest)
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+)
+
+// middlewareRecoverer turns a panic in a handler into a logged stack trace and
+// a generic 500, so one bad request doesn't take its connection down with it.
+// If the handler already started its response a 500 can no longer be sent, so
+// the response is aborted with http.ErrAbortHandler instead and the client sees
+// a truncated response rather than a 500 tacked onto a partial body.
+func middlewareRecoverer(logger *slog.Logger, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		defer func() {
+			err := recover()
+			if err == nil {
+				return
+			}
+			// net/http uses this panic to abort a response on purpose
+			if err == http.ErrAbortHandler {
+				panic(err)
+			}
+			logger.Error("panic serving request",
+				slog.String("method", r.Method),
+				slog.String("path", r.URL.Path),
+				slog.String("request_id", requestIDFromContext(r.Context())),
+				slog.Any("panic", err),
+				slog.String("stack", string(debug.Stack())),
+			)
+			if rec.wroteHeader {
+				panic(http.ErrAbortHandler)
+			}
+			respondWithError(w, http.StatusInternalServerError, "Internal Server Error")
+		}()
+		next.ServeHTTP(rec, r)
+	})
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMiddlewareRecoverer(t *testing.T) {
+	tests := []struct {
+		name      string
+		handler   http.HandlerFunc
+		wantAbort bool
+	}{
+		{
+			name:    "panic before writing",
+			handler: func(w http.ResponseWriter, r *http.Request) { panic("boom") },
+		},
+		{
+			name: "panic after WriteHeader",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusAccepted)
+				panic("boom")
+			},
+			wantAbort: true,
+		},
+		{
+			name: "panic after Write",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte("partial"))
+				panic("boom")
+			},
+			wantAbort: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var logs bytes.Buffer
+			logger := slog.New(slog.NewTextHandler(&logs, nil))
+			h := middlewareLogger(logger, middlewareRecoverer(logger, tt.handler))
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/v1/err", nil)
+			aborted := func() (aborted bool) {
+				defer func() {
+					if err := recover(); err != nil {
+						if err != http.ErrAbortHandler {
+							t.Fatalf("panicked with %v, want %v", err, http.ErrAbortHandler)
+						}
+						aborted = true
+					}
+				}()
+				h.ServeHTTP(w, r)
+				return false
+			}()
+
+			if aborted != tt.wantAbort {
+				t.Fatalf("aborted: %t, want %t", aborted, tt.wantAbort)
+			}
+			if !strings.Contains(logs.String(), "panic serving request") {
+				t.Errorf("panic not logged: %s", logs.String())
+			}
+			if !strings.Contains(logs.String(), "msg=request") {
+				t.Errorf("request not logged: %s", logs.String())
+			}
+			if tt.wantAbort {
+				if strings.Contains(w.Body.String(), "Internal Server Error") {
+					t.Errorf("error JSON written after the response started: %q", w.Body)
+				}
+				return
+			}
+			if w.Code != http.StatusInternalServerError {
+				t.Fatalf("status %d, want %d", w.Code, http.StatusInternalServerError)
+			}
+			var body map[string]string
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil || body["error"] == "" {
+				t.Errorf("body %q, want the error JSON", w.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// maxRequestIDLength caps the X-Request-ID accepted from clients so it can't
+// bloat log lines.
+const maxRequestIDLength = 128
+
+type requestIDKey struct{}
+
+// middlewareRequestID tags each request with an ID, taken from the
+// X-Request-ID header when the client sends one or generated otherwise. The ID
+// is stored in the request context, echoed in the response header and set on
+// the request's server span.
+func middlewareRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get("X-Request-ID")
+		if id == "" || len(id) > maxRequestIDLength {
+			id = uuid.New().String()
+		}
+		w.Header().Set("X-Request-ID", id)
+		trace.SpanFromContext(r.Context()).SetAttributes(attribute.String("request.id", id))
+		ctx := context.WithValue(r.Context(), requestIDKey{}, id)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// requestIDFromContext returns the request ID set by middlewareRequestID, or
+// "" if there is none.
+func requestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
@@ -2,3 +2,11 @@
 INSERT INTO users (id, created_at, updated_at, name)
 VALUES ($1, $2, $3, $4)
 RETURNING *;
+
+-- name: ListUsers :many
+SELECT * FROM users
+ORDER BY created_at, id
+LIMIT $1 OFFSET $2;
+
+-- name: CountUsers :one
+SELECT COUNT(*) FROM users;
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// timeFormat controls how timestamps are rendered in JSON responses.
+type timeFormat string
+
+const (
+	timeFormatRFC3339Nano timeFormat = "rfc3339nano"
+	timeFormatRFC3339     timeFormat = "rfc3339"
+	timeFormatUnix        timeFormat = "unix"
+)
+
+func parseTimeFormat(s string) (timeFormat, error) {
+	switch f := timeFormat(strings.ToLower(s)); f {
+	case timeFormatRFC3339Nano, timeFormatRFC3339, timeFormatUnix:
+		return f, nil
+	}
+	return "", fmt.Errorf("unknown time format %q", s)
+}
+
+// requestTimeFormat returns the time format for a response. Clients can
+// override the configured default with a time-format parameter on the Accept
+// header, e.g. "Accept: application/json; time-format=unix". Unknown values
+// fall back to the default. Since the body then depends on Accept, it adds
+// Vary: Accept to the response so shared caches keep the formats apart.
+func (cfg *apiConfig) requestTimeFormat(w http.ResponseWriter, r *http.Request) timeFormat {
+	w.Header().Add("Vary", "Accept")
+	for _, accept := range strings.Split(r.Header.Get("Accept"), ",") {
+		_, params, err := mime.ParseMediaType(accept)
+		if err != nil {
+			continue
+		}
+		if f, err := parseTimeFormat(params["time-format"]); err == nil {
+			return f
+		}
+	}
+	return cfg.TimeFormat
+}
+
+// jsonTime wraps a time.Time so it marshals in the given format.
+type jsonTime struct {
+	time   time.Time
+	format timeFormat
+}
+
+func (t jsonTime) MarshalJSON() ([]byte, error) {
+	switch t.format {
+	case timeFormatRFC3339:
+		return json.Marshal(t.time.Format(time.RFC3339))
+	case timeFormatUnix:
+		return json.Marshal(t.time.Unix())
+	default:
+		return json.Marshal(t.time.Format(time.RFC3339Nano))
+	}
+}
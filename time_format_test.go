@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestParseTimeFormat(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    timeFormat
+		wantErr bool
+	}{
+		{"rfc3339nano", timeFormatRFC3339Nano, false},
+		{"rfc3339", timeFormatRFC3339, false},
+		{"unix", timeFormatUnix, false},
+		{"UNIX", timeFormatUnix, false},
+		{"RFC3339", timeFormatRFC3339, false},
+		{"", "", true},
+		{"iso8601", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := parseTimeFormat(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseTimeFormat(%q) error %v, want error %t", tt.in, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parseTimeFormat(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequestTimeFormat(t *testing.T) {
+	cfg := &apiConfig{TimeFormat: timeFormatRFC3339}
+	tests := []struct {
+		name   string
+		accept string
+		want   timeFormat
+	}{
+		{"no Accept", "", timeFormatRFC3339},
+		{"no parameter", "application/json", timeFormatRFC3339},
+		{"unix", "application/json; time-format=unix", timeFormatUnix},
+		{"case-insensitive value", "application/json; time-format=RFC3339Nano", timeFormatRFC3339Nano},
+		{"unknown value falls back", "application/json; time-format=iso8601", timeFormatRFC3339},
+		{"second media range", "text/html, application/json; time-format=unix", timeFormatUnix},
+		{"first known value wins", "application/json; time-format=bogus, application/*; time-format=rfc3339nano, */*; time-format=unix", timeFormatRFC3339Nano},
+		{"malformed range skipped", ";;;, application/json; time-format=unix", timeFormatUnix},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/v1/admin/users", nil)
+			if tt.accept != "" {
+				r.Header.Set("Accept", tt.accept)
+			}
+			if got := cfg.requestTimeFormat(w, r); got != tt.want {
+				t.Errorf("requestTimeFormat with Accept %q = %q, want %q", tt.accept, got, tt.want)
+			}
+			if got := w.Header().Get("Vary"); got != "Accept" {
+				t.Errorf("Vary %q, want Accept", got)
+			}
+		})
+	}
+}
+
+func TestJSONTimeMarshal(t *testing.T) {
+	ts := time.Date(2024, 3, 1, 12, 30, 45, 123456789, time.UTC)
+	tests := []struct {
+		format timeFormat
+		want   string
+	}{
+		{timeFormatRFC3339Nano, `"2024-03-01T12:30:45.123456789Z"`},
+		{timeFormatRFC3339, `"2024-03-01T12:30:45Z"`},
+		{timeFormatUnix, `1709296245`},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.format), func(t *testing.T) {
+			got, err := json.Marshal(jsonTime{time: ts, format: tt.format})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("marshaled %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
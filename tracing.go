@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+const serviceName = "blogaggregator"
+
+// tracer is used for spans that are not created by the HTTP instrumentation,
+// such as database calls made from handlers.
+var tracer = otel.Tracer(serviceName)
+
+// setupTracing configures an OTLP trace exporter when
+// OTEL_EXPORTER_OTLP_ENDPOINT is set. Without it the global no-op provider is
+// left in place. The returned function flushes and stops the exporter.
+func setupTracing(ctx context.Context) (func(context.Context) error, error) {
+	if os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	// The exporter reads the endpoint and any headers from the standard OTEL_* variables
+	exporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewWithAttributes(
+			semconv.SchemaURL,
+			semconv.ServiceName(serviceName),
+		)),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	return provider.Shutdown, nil
+}
+
+// routeSpanNames names server spans after the method and the mux pattern that
+// matched, such as "GET /v1/admin/users", rather than the raw path, so spans for
+// the same route group together. Requests under /v1/admin/ are looked up on
+// adminMux to get the admin route rather than the mount point.
+func routeSpanNames(mux, adminMux *http.ServeMux) func(string, *http.Request) string {
+	return func(_ string, r *http.Request) string {
+		_, pattern := mux.Handler(r)
+		if pattern == "/v1/admin/" {
+			_, pattern = adminMux.Handler(r)
+		}
+		if pattern == "" {
+			pattern = "unmatched"
+		}
+		return r.Method + " " + pattern
+	}
+}
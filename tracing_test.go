@@ -0,0 +1,33 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRouteSpanNames(t *testing.T) {
+	mux, adminMux := http.NewServeMux(), http.NewServeMux()
+	mux.HandleFunc("/", rootHandler)
+	mux.HandleFunc("/v1/users", createUserHandler(&apiConfig{}))
+	mux.Handle("/v1/admin/", adminMux)
+	adminMux.HandleFunc("/v1/admin/users", listUsersHandler(&apiConfig{}))
+	name := routeSpanNames(mux, adminMux)
+
+	tests := []struct {
+		method, target, want string
+	}{
+		{http.MethodPost, "/v1/users", "POST /v1/users"},
+		{http.MethodGet, "/v1/admin/users?limit=5", "GET /v1/admin/users"},
+		{http.MethodGet, "/some/unknown/path", "GET /"},
+		{http.MethodGet, "/v1/admin/nope", "GET unmatched"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, tt.target, nil)
+			if got := name("", r); got != tt.want {
+				t.Errorf("span name %q, want %q", got, tt.want)
+			}
+		})
+	}
+}